@@ -0,0 +1,111 @@
+// Kernel-side BPF filtering for the netlink process connector.
+//
+// Without a filter, every fork/exec/exit (and now UID/GID/SID/PTRACE/COMM/
+// COREDUMP) event for the entire host is copied into userspace before we
+// get a chance to discard it; on busy systems that Recvfrom loop can peg a
+// core. attachFilter compiles a classic BPF program that the kernel runs
+// against each message before it ever reaches our socket buffer, dropping
+// anything that doesn't match the requested event mask or PID whitelist.
+package psevent
+
+import (
+	"syscall"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// Byte offsets into the netlink payload, exactly as it arrives from
+// Recvfrom: a struct nlmsghdr, followed by struct cn_msg, followed by
+// struct proc_event (see netlink_linux.go for the Go mirrors of these
+// kernel structs). The attached socket filter sees the whole datagram, so
+// every offset below is relative to the nlmsghdr, not to cn_msg.
+const (
+	bpfOffCnIdx = syscall.NLMSG_HDRLEN + 0  // cn_msg.id.idx
+	bpfOffCnVal = syscall.NLMSG_HDRLEN + 4  // cn_msg.id.val
+	bpfOffWhat  = syscall.NLMSG_HDRLEN + 20 // proc_event.what
+
+	// bpfOffTgid is process_tgid's offset for every proc_event union
+	// member except fork, which is the second uint32 following
+	// process_pid in exec/exit/id/sid/ptrace/comm/coredump. Fork events
+	// carry parent and child pids instead of a single process_tgid at
+	// this offset, so they're always let through below and left to
+	// handleEvent's watch table for fine-grained matching.
+	bpfOffTgid = syscall.NLMSG_HDRLEN + 36 + 4
+)
+
+// buildFilter compiles a program that accepts only proc-connector
+// messages (cn_msg.id == {CN_IDX_PROC, CN_VAL_PROC}) whose proc_event.what
+// intersects mask (0 means "any type") and, when pids is non-empty, whose
+// process_tgid is one of pids. Fork events, which have no process_tgid at
+// bpfOffTgid, are not checked against pids.
+func buildFilter(mask uint32, pids []int) ([]unix.SockFilter, error) {
+	// Classic BPF absolute loads read words in network (big-endian) byte
+	// order, while the proc connector writes them in host byteOrder, so
+	// every constant compared against a loaded word is byte-swapped.
+	be := func(v uint32) uint32 { return v>>24 | v>>8&0xff00 | v<<8&0xff0000 | v<<24 }
+
+	prog := []bpf.Instruction{
+		bpf.LoadAbsolute{Off: bpfOffCnIdx, Size: 4},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: be(_CN_IDX_PROC), SkipTrue: 1},
+		bpf.RetConstant{Val: 0},
+		bpf.LoadAbsolute{Off: bpfOffCnVal, Size: 4},
+		bpf.JumpIf{Cond: bpf.JumpEqual, Val: be(_CN_VAL_PROC), SkipTrue: 1},
+		bpf.RetConstant{Val: 0},
+	}
+
+	if mask != 0 {
+		prog = append(prog,
+			bpf.LoadAbsolute{Off: bpfOffWhat, Size: 4},
+			bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: be(mask), SkipTrue: 1},
+			bpf.RetConstant{Val: 0},
+		)
+	}
+
+	if len(pids) > 0 {
+		if mask == 0 || mask&PROC_EVENT_FORK != 0 {
+			prog = append(prog,
+				bpf.LoadAbsolute{Off: bpfOffWhat, Size: 4},
+				bpf.JumpIf{Cond: bpf.JumpEqual, Val: be(PROC_EVENT_FORK), SkipTrue: uint8(len(pids) + 2)},
+			)
+		}
+
+		prog = append(prog, bpf.LoadAbsolute{Off: bpfOffTgid, Size: 4})
+		for i, pid := range pids {
+			prog = append(prog, bpf.JumpIf{
+				Cond:     bpf.JumpEqual,
+				Val:      be(uint32(pid)),
+				SkipTrue: uint8(len(pids)-1-i) + 1,
+			})
+		}
+		prog = append(prog, bpf.RetConstant{Val: 0})
+	}
+
+	prog = append(prog, bpf.RetConstant{Val: 0xffff})
+
+	raw, err := bpf.Assemble(prog)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := make([]unix.SockFilter, len(raw))
+	for i, r := range raw {
+		filter[i] = unix.SockFilter{Code: r.Op, Jt: r.Jt, Jf: r.Jf, K: r.K}
+	}
+	return filter, nil
+}
+
+// attachFilter compiles and installs a BPF filter on sock via
+// SO_ATTACH_FILTER, so the kernel drops uninteresting proc-connector
+// messages before they're ever queued for us to Recvfrom.
+func attachFilter(sock int, mask uint32, pids []int) error {
+	filter, err := buildFilter(mask, pids)
+	if err != nil {
+		return err
+	}
+
+	return unix.SetsockoptSockFprog(sock, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	})
+}
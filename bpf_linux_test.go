@@ -0,0 +1,62 @@
+package psevent
+
+import (
+	"syscall"
+	"testing"
+)
+
+// TestBuildFilterOffsets guards against the bug where the compiled program
+// read fields relative to cn_msg instead of the nlmsghdr that actually
+// precedes it on the wire - which made the very first comparison fail for
+// every real proc-connector message and silently dropped 100% of events.
+func TestBuildFilterOffsets(t *testing.T) {
+	filter, err := buildFilter(PROC_EVENT_EXIT, []int{1234})
+	if err != nil {
+		t.Fatalf("buildFilter: %v", err)
+	}
+
+	const wantLen = 13
+	if len(filter) != wantLen {
+		t.Fatalf("len(filter) = %d, want %d", len(filter), wantLen)
+	}
+
+	checks := []struct {
+		idx  int
+		name string
+		want uint32
+	}{
+		{0, "cn_msg.id.idx", bpfOffCnIdx},
+		{3, "cn_msg.id.val", bpfOffCnVal},
+		{6, "proc_event.what", bpfOffWhat},
+		{9, "process_tgid", bpfOffTgid},
+	}
+	for _, c := range checks {
+		if got := filter[c.idx].K; got != c.want {
+			t.Errorf("%s load offset = %d, want %d", c.name, got, c.want)
+		}
+	}
+
+	for _, off := range []uint32{bpfOffCnIdx, bpfOffCnVal, bpfOffWhat, bpfOffTgid} {
+		if off < syscall.NLMSG_HDRLEN {
+			t.Fatalf("offset %d precedes the %d-byte nlmsghdr the filter actually sees", off, syscall.NLMSG_HDRLEN)
+		}
+	}
+}
+
+// TestBuildFilterNoFilter asserts the shape of the program when neither an
+// event mask nor a PID whitelist is requested: just the proc-connector
+// sanity checks followed by an unconditional accept.
+func TestBuildFilterNoFilter(t *testing.T) {
+	filter, err := buildFilter(0, nil)
+	if err != nil {
+		t.Fatalf("buildFilter: %v", err)
+	}
+
+	const wantLen = 7
+	if len(filter) != wantLen {
+		t.Fatalf("len(filter) = %d, want %d", len(filter), wantLen)
+	}
+	if got := filter[len(filter)-1].K; got != 0xffff {
+		t.Fatalf("final instruction should unconditionally accept (K=0xffff), got %#x", got)
+	}
+}
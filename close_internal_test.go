@@ -0,0 +1,39 @@
+package psevent
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHandleEventUnblocksOnClose reproduces the leak chunk0-5's original
+// epoll/eventfd fix didn't cover: a goroutine isn't blocked in
+// waitReadable()/Recvfrom, it's blocked inside handleEvent trying to
+// deliver an event nobody is draining - exactly what happens when
+// PWaitAny's deferred Close() runs while readEvents is mid-send on some
+// other channel. Close() must still be able to unstick it.
+func TestHandleEventUnblocksOnClose(t *testing.T) {
+	p := newPsEvent(nil)
+
+	// Nobody reads from p.UID, so handleEvent must block on the send
+	// until something else happens.
+	data := encodeProcEvent(t, PROC_EVENT_UID, 0, 0, &idProcEvent{ProcessPid: 1, ProcessTgid: 1})
+
+	stopped := make(chan bool, 1)
+	go func() { stopped <- p.handleEvent(data) }()
+
+	// Give the goroutine a chance to actually reach the blocking send
+	// before we signal done, so this test would hang (not pass
+	// trivially) if the select-against-done guard were missing.
+	time.Sleep(20 * time.Millisecond)
+
+	p.done <- true
+
+	select {
+	case stop := <-stopped:
+		if !stop {
+			t.Fatal("handleEvent returned without reporting stop")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handleEvent stayed blocked on the undrained send after done was signaled - readEvents would leak")
+	}
+}
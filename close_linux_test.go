@@ -0,0 +1,37 @@
+package psevent_test
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/dean2021/psevent"
+)
+
+// TestCloseStopsReadEventsGoroutine exercises the chunk0-5 shutdown path:
+// Close() must wake readEvents() out of waitReadable() rather than leaving
+// it parked forever.
+func TestCloseStopsReadEventsGoroutine(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	w, err := psevent.Listen()
+	if err != nil {
+		t.Skipf("proc connector unavailable in this environment: %v", err)
+	}
+
+	// Give readEvents a moment to start and block in waitReadable().
+	time.Sleep(50 * time.Millisecond)
+
+	if err := w.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if runtime.NumGoroutine() <= before {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("goroutine count stayed at %d (was %d before Listen); readEvents leaked", runtime.NumGoroutine(), before)
+}
@@ -0,0 +1,40 @@
+package psevent_test
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/dean2021/psevent"
+)
+
+// TestListenWithOptionsObservesFilteredExit attaches the BPF filter built
+// by chunk0-3 to a real proc-connector socket and asserts a real child's
+// exit still makes it through - this is exactly the case the wrong
+// cn_msg-relative offsets silently broke: every real message failed the
+// first comparison and was dropped before reaching userspace.
+func TestListenWithOptionsObservesFilteredExit(t *testing.T) {
+	w, err := psevent.ListenWithOptions(psevent.Options{EventMask: psevent.PROC_EVENT_EXIT})
+	if err != nil {
+		t.Skipf("proc connector unavailable in this environment: %v", err)
+	}
+	defer w.Close()
+
+	cmd := exec.Command("/bin/true")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	pid := cmd.Process.Pid
+	go func() { _ = cmd.Wait() }()
+
+	select {
+	case ev := <-w.Exit:
+		if ev.Pid != pid {
+			t.Fatalf("got exit for pid %d, want %d", ev.Pid, pid)
+		}
+	case err := <-w.Error:
+		t.Fatalf("listener error: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the filtered exit event - the BPF filter is likely dropping it")
+	}
+}
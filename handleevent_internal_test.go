@@ -0,0 +1,97 @@
+package psevent
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// encodeProcEvent builds the raw bytes handleEvent expects: a cnMsg, the
+// proc_event header (what/cpu/timestamp_ns), followed by the event-kind
+// specific payload - mirroring what actually arrives over the netlink
+// socket, without needing a kernel to produce it.
+func encodeProcEvent(t *testing.T, what, cpu uint32, timestampNs uint64, payload interface{}) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := binary.Write(buf, byteOrder, &cnMsg{}); err != nil {
+		t.Fatalf("encode cnMsg: %v", err)
+	}
+	hdr := &procEventHeader{What: what, Cpu: cpu, Timestamp: timestampNs}
+	if err := binary.Write(buf, byteOrder, hdr); err != nil {
+		t.Fatalf("encode procEventHeader: %v", err)
+	}
+	if err := binary.Write(buf, byteOrder, payload); err != nil {
+		t.Fatalf("encode payload: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// TestHandleEventNewEventKinds feeds handleEvent synthetic bytes for each
+// of the chunk0-1 event kinds and asserts the parsed fields, the same way
+// bpf_linux_test.go exercises buildFilter as pure logic without a root
+// netlink socket.
+func TestHandleEventNewEventKinds(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+		recv func(p *PsEvent) interface{}
+		want interface{}
+	}{
+		{
+			name: "uid",
+			data: encodeProcEvent(t, PROC_EVENT_UID, 1, 100, &idProcEvent{ProcessPid: 10, ProcessTgid: 11, R: 1000, E: 1001}),
+			recv: func(p *PsEvent) interface{} { return <-p.UID },
+			want: &ProcEventUID{EventMeta: EventMeta{CPU: 1, TimestampNs: 100}, Pid: 11, RUID: 1000, EUID: 1001},
+		},
+		{
+			name: "gid",
+			data: encodeProcEvent(t, PROC_EVENT_GID, 2, 200, &idProcEvent{ProcessPid: 20, ProcessTgid: 21, R: 2000, E: 2001}),
+			recv: func(p *PsEvent) interface{} { return <-p.GID },
+			want: &ProcEventGID{EventMeta: EventMeta{CPU: 2, TimestampNs: 200}, Pid: 21, RGID: 2000, EGID: 2001},
+		},
+		{
+			name: "sid",
+			data: encodeProcEvent(t, PROC_EVENT_SID, 3, 300, &sidProcEvent{ProcessPid: 30, ProcessTgid: 31}),
+			recv: func(p *PsEvent) interface{} { return <-p.SID },
+			want: &ProcEventSID{EventMeta: EventMeta{CPU: 3, TimestampNs: 300}, Pid: 31},
+		},
+		{
+			name: "ptrace",
+			data: encodeProcEvent(t, PROC_EVENT_PTRACE, 4, 400, &ptraceProcEvent{ProcessPid: 40, ProcessTgid: 41, TracerPid: 5, TracerTgid: 6}),
+			recv: func(p *PsEvent) interface{} { return <-p.Ptrace },
+			want: &ProcEventPtrace{EventMeta: EventMeta{CPU: 4, TimestampNs: 400}, Pid: 41, TracerPid: 6},
+		},
+		{
+			name: "comm",
+			data: encodeProcEvent(t, PROC_EVENT_COMM, 5, 500, &commProcEvent{ProcessPid: 50, ProcessTgid: 51, Comm: [16]byte{'s', 'l', 'e', 'e', 'p'}}),
+			recv: func(p *PsEvent) interface{} { return <-p.Comm },
+			want: &ProcEventComm{EventMeta: EventMeta{CPU: 5, TimestampNs: 500}, Pid: 51, Comm: "sleep"},
+		},
+		{
+			name: "coredump",
+			data: encodeProcEvent(t, PROC_EVENT_COREDUMP, 6, 600, &coredumpProcEvent{ProcessPid: 60, ProcessTgid: 61}),
+			recv: func(p *PsEvent) interface{} { return <-p.Coredump },
+			want: &ProcEventCoredump{EventMeta: EventMeta{CPU: 6, TimestampNs: 600}, Pid: 61},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			p := newPsEvent(nil)
+
+			got := make(chan interface{}, 1)
+			go func() { got <- c.recv(p) }()
+
+			if stop := p.handleEvent(c.data); stop {
+				t.Fatal("handleEvent reported stop without done being signaled")
+			}
+
+			ev := <-got
+			if !reflect.DeepEqual(ev, c.want) {
+				t.Fatalf("got %+v, want %+v", ev, c.want)
+			}
+		})
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"encoding/binary"
 	"os"
 	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
 const (
@@ -19,9 +21,15 @@ const (
 	_PROC_CN_MCAST_IGNORE = 2
 
 	// Flags (from <linux/cn_proc.h>)
-	PROC_EVENT_FORK = 0x00000001 // fork() events
-	PROC_EVENT_EXEC = 0x00000002 // exec() events
-	PROC_EVENT_EXIT = 0x80000000 // exit() events
+	PROC_EVENT_FORK     = 0x00000001 // fork() events
+	PROC_EVENT_EXEC     = 0x00000002 // exec() events
+	PROC_EVENT_UID      = 0x00000004 // setuid() events
+	PROC_EVENT_GID      = 0x00000040 // setgid() events
+	PROC_EVENT_SID      = 0x00000080 // setsid() events
+	PROC_EVENT_PTRACE   = 0x00000100 // ptrace attach/detach events
+	PROC_EVENT_COMM     = 0x00000200 // process rename events
+	PROC_EVENT_COREDUMP = 0x40000000 // coredump events
+	PROC_EVENT_EXIT     = 0x80000000 // exit() events
 )
 
 var (
@@ -70,6 +78,45 @@ type exitProcEvent struct {
 	ProcessTgid uint32
 	ExitCode    uint32
 	ExitSignal  uint32
+	ParentPid   uint32
+	ParentTgid  uint32
+}
+
+// linux/cn_proc.h: struct proc_event.id (used for both UID and GID events)
+type idProcEvent struct {
+	ProcessPid  uint32
+	ProcessTgid uint32
+	R           uint32 // ruid or rgid, depending on proc_event.what
+	E           uint32 // euid or egid, depending on proc_event.what
+}
+
+// linux/cn_proc.h: struct proc_event.sid
+type sidProcEvent struct {
+	ProcessPid  uint32
+	ProcessTgid uint32
+}
+
+// linux/cn_proc.h: struct proc_event.ptrace
+type ptraceProcEvent struct {
+	ProcessPid  uint32
+	ProcessTgid uint32
+	TracerPid   uint32
+	TracerTgid  uint32
+}
+
+// linux/cn_proc.h: struct proc_event.comm
+type commProcEvent struct {
+	ProcessPid  uint32
+	ProcessTgid uint32
+	Comm        [16]byte
+}
+
+// linux/cn_proc.h: struct proc_event.coredump
+type coredumpProcEvent struct {
+	ProcessPid  uint32
+	ProcessTgid uint32
+	ParentPid   uint32
+	ParentTgid  uint32
 }
 
 // standard netlink header + connector header
@@ -82,23 +129,34 @@ type NetLink struct {
 	addr *syscall.SockaddrNetlink // Netlink socket address
 	sock int                      // The syscall.Socket() file descriptor
 	seq  uint32                   // struct cn_msg.seq
+
+	epfd   int // epoll instance multiplexing sock and wakeFd
+	wakeFd int // eventfd that close() signals to unblock waitReadable()
 }
 
 // Initialize linux implementation of the eventListener interface
-func createListener() (eventListener, error) {
+func createListener(opts Options) (eventListener, error) {
 	nl := &NetLink{}
-	err := nl.bind()
+	err := nl.bind(opts)
 	return nl, err
 }
 
-// Bind our netlink socket and
-// send a listen control message to the connector driver.
-func (listener *NetLink) bind() error {
+// Bind our netlink socket, attach the optional BPF filter built from opts,
+// and send a listen control message to the connector driver.
+func (listener *NetLink) bind(opts Options) error {
 
-	sock, err := syscall.Socket(
-		syscall.AF_NETLINK,
-		syscall.SOCK_DGRAM,
-		syscall.NETLINK_CONNECTOR)
+	var sock int
+	err := withNamespace(opts.Namespace, func() error {
+		s, err := syscall.Socket(
+			syscall.AF_NETLINK,
+			syscall.SOCK_DGRAM,
+			syscall.NETLINK_CONNECTOR)
+		if err != nil {
+			return err
+		}
+		sock = s
+		return nil
+	})
 
 	if err != nil {
 		return err
@@ -110,20 +168,101 @@ func (listener *NetLink) bind() error {
 		Groups: _CN_IDX_PROC,
 	}
 
+	if opts.EventMask != 0 || len(opts.PIDs) > 0 {
+		if err := attachFilter(listener.sock, opts.EventMask, opts.PIDs); err != nil {
+			_ = syscall.Close(listener.sock)
+			return err
+		}
+	}
+
 	err = syscall.Bind(listener.sock, listener.addr)
 
 	if err != nil {
 		return err
 	}
 
+	if err := listener.setupEpoll(); err != nil {
+		_ = syscall.Close(listener.sock)
+		return err
+	}
+
 	return listener.send(_PROC_CN_MCAST_LISTEN)
 }
 
+// setupEpoll creates the epoll instance readEvents() blocks on, registering
+// both the netlink socket and a private eventfd that close() signals to
+// wake a blocked epoll_wait without leaving it spinning on a closed fd.
+func (listener *NetLink) setupEpoll() error {
+	epfd, err := unix.EpollCreate1(unix.EPOLL_CLOEXEC)
+	if err != nil {
+		return err
+	}
+
+	wakeFd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+	if err != nil {
+		_ = unix.Close(epfd)
+		return err
+	}
+
+	if err := unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, listener.sock, &unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(listener.sock)}); err != nil {
+		_ = unix.Close(wakeFd)
+		_ = unix.Close(epfd)
+		return err
+	}
+	if err := unix.EpollCtl(epfd, unix.EPOLL_CTL_ADD, wakeFd, &unix.EpollEvent{Events: unix.EPOLLIN, Fd: int32(wakeFd)}); err != nil {
+		_ = unix.Close(wakeFd)
+		_ = unix.Close(epfd)
+		return err
+	}
+
+	listener.epfd = epfd
+	listener.wakeFd = wakeFd
+	return nil
+}
+
+// waitReadable blocks until either the netlink socket has data ready
+// (returns true) or wake() has signaled shutdown via wakeFd (returns
+// false). EINTR is retried transparently.
+func (listener *NetLink) waitReadable() (bool, error) {
+	var events [2]unix.EpollEvent
+
+	for {
+		n, err := unix.EpollWait(listener.epfd, events[:], -1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return false, err
+		}
+
+		woken := false
+		for i := 0; i < n; i++ {
+			if int(events[i].Fd) == listener.wakeFd {
+				woken = true
+			}
+		}
+		if woken {
+			return false, nil
+		}
+		return true, nil
+	}
+}
+
+// wake unblocks a goroutine parked in waitReadable() by writing to wakeFd.
+func (listener *NetLink) wake() error {
+	buf := make([]byte, 8)
+	byteOrder.PutUint64(buf, 1)
+	_, err := unix.Write(listener.wakeFd, buf)
+	return err
+}
+
 // Send an ignore control message to the connector driver
 // and close our netlink socket.
 func (listener *NetLink) close() error {
 	err := listener.send(_PROC_CN_MCAST_IGNORE)
 	_ = syscall.Close(listener.sock)
+	_ = unix.Close(listener.wakeFd)
+	_ = unix.Close(listener.epfd)
 	return err
 }
 
@@ -0,0 +1,61 @@
+// Network namespace support. The netlink proc connector is scoped to the
+// network namespace its socket was created in, so observing processes in
+// another namespace (a container, typically) requires creating that
+// socket there - mirroring what vishvananda/netlink does for other
+// netlink families.
+package psevent
+
+import (
+	"runtime"
+
+	"github.com/vishvananda/netns"
+)
+
+// ListenInNamespace is like Listen, but creates the netlink socket inside
+// ns instead of the caller's network namespace.
+func ListenInNamespace(ns netns.NsHandle) (*PsEvent, error) {
+	return ListenWithOptions(Options{Namespace: ns})
+}
+
+// NamespaceByPid opens the network namespace of pid (/proc/<pid>/ns/net),
+// suitable for use as Options.Namespace or ListenInNamespace's argument.
+// The caller is responsible for closing the returned handle once done
+// with it.
+func NamespaceByPid(pid int) (netns.NsHandle, error) {
+	return netns.GetFromPid(pid)
+}
+
+// NamespaceByPath opens the network namespace bind-mounted at path (e.g.
+// "/var/run/netns/foo"), suitable for use as Options.Namespace or
+// ListenInNamespace's argument. The caller is responsible for closing the
+// returned handle once done with it.
+func NamespaceByPath(path string) (netns.NsHandle, error) {
+	return netns.GetFromPath(path)
+}
+
+// withNamespace locks the calling goroutine to its current OS thread,
+// switches into ns for the duration of fn, and restores the original
+// namespace before returning. Both ns's Go zero value (an unset
+// Options.Namespace) and netns.None() mean "stay in the current
+// namespace", and run fn without touching any of this.
+func withNamespace(ns netns.NsHandle, fn func() error) error {
+	if ns == 0 || !ns.IsOpen() {
+		return fn()
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	origin, err := netns.Get()
+	if err != nil {
+		return err
+	}
+	defer origin.Close()
+
+	if err := netns.Set(ns); err != nil {
+		return err
+	}
+	defer func() { _ = netns.Set(origin) }()
+
+	return fn()
+}
@@ -11,19 +11,73 @@ import (
 	"encoding/binary"
 	"sync"
 	"syscall"
+
+	"github.com/vishvananda/netns"
 )
 
+// EventMeta is embedded in every Proc event and carries the fields common
+// to linux/cn_proc.h's struct proc_event: the CPU the event was raised on
+// and its kernel timestamp, so consumers can correlate events with perf
+// traces and compute latencies.
+type EventMeta struct {
+	CPU         uint32 // CPU the event was raised on
+	TimestampNs uint64 // Kernel timestamp of the event, in nanoseconds
+}
+
 type ProcEventFork struct {
+	EventMeta
 	ParentPid int // Pid of the process that called fork()
 	ChildPid  int // Child process pid created by fork()
 }
 
 type ProcEventExec struct {
+	EventMeta
 	Pid int // Pid of the process that called exec()
 }
 
 type ProcEventExit struct {
-	Pid int // Pid of the process that called exit()
+	EventMeta
+	Pid        int // Pid of the process that called exit()
+	ExitCode   int // Exit code passed to exit()/_exit()
+	ExitSignal int // Signal that caused the process to terminate, if any
+	ParentPid  int // Pid of the parent notified of the exit
+	ParentTgid int // Tgid of the parent notified of the exit
+}
+
+type ProcEventUID struct {
+	EventMeta
+	Pid  int // Pid of the process that called setuid()/setreuid()
+	RUID int // Real UID
+	EUID int // Effective UID
+}
+
+type ProcEventGID struct {
+	EventMeta
+	Pid  int // Pid of the process that called setgid()/setregid()
+	RGID int // Real GID
+	EGID int // Effective GID
+}
+
+type ProcEventSID struct {
+	EventMeta
+	Pid int // Pid of the process that called setsid()
+}
+
+type ProcEventPtrace struct {
+	EventMeta
+	Pid       int // Pid of the process being traced
+	TracerPid int // Pid of the tracer, 0 on detach
+}
+
+type ProcEventComm struct {
+	EventMeta
+	Pid  int    // Pid of the process that was renamed
+	Comm string // New command name (from prctl/pthread_setname_np)
+}
+
+type ProcEventCoredump struct {
+	EventMeta
+	Pid int // Pid of the process that core dumped
 }
 
 type watch struct {
@@ -40,52 +94,153 @@ type PsEvent struct {
 	watches      map[int]*watch // Map of watched process ids
 	watchesMutex *sync.Mutex
 
-	Error chan error          // Errors are sent on this channel
-	Fork  chan *ProcEventFork // Fork events are sent on this channel
-	Exec  chan *ProcEventExec // Exec events are sent on this channel
-	Exit  chan *ProcEventExit // Exit events are sent on this channel
-	done  chan bool           // Used to stop the readEvents() goroutine
+	Error    chan error              // Errors are sent on this channel
+	Fork     chan *ProcEventFork     // Fork events are sent on this channel
+	Exec     chan *ProcEventExec     // Exec events are sent on this channel
+	Exit     chan *ProcEventExit     // Exit events are sent on this channel
+	UID      chan *ProcEventUID      // setuid() events are sent on this channel
+	GID      chan *ProcEventGID      // setgid() events are sent on this channel
+	SID      chan *ProcEventSID      // setsid() events are sent on this channel
+	Ptrace   chan *ProcEventPtrace   // ptrace attach/detach events are sent on this channel
+	Comm     chan *ProcEventComm     // comm rename events are sent on this channel
+	Coredump chan *ProcEventCoredump // coredump events are sent on this channel
+	done     chan bool               // Used to stop the readEvents() goroutine
 
 	isClosed    bool // Set to true when Close() is first called
 	closedMutex *sync.Mutex
 }
 
+// Options configures a listener created via ListenWithOptions.
+type Options struct {
+	// EventMask restricts delivery, at the kernel filter level, to
+	// proc_event kinds whose bit is set here (PROC_EVENT_FORK,
+	// PROC_EVENT_EXEC, ...). Zero delivers every event kind, matching
+	// the behavior of Listen().
+	EventMask uint32
+
+	// PIDs, when non-empty, further restricts delivery to events whose
+	// process_tgid is in this set. Fork events are not checked against
+	// PIDs. Zero value (nil/empty) disables PID filtering.
+	PIDs []int
+
+	// Namespace, when set, creates the netlink socket inside that
+	// network namespace instead of the caller's, so a host-level agent
+	// can observe processes in a container's netns. Its zero value
+	// means "use the caller's current namespace"; obtain a handle via
+	// NamespaceByPid, NamespaceByPath, or github.com/vishvananda/netns.
+	Namespace netns.NsHandle
+}
+
 // Initialize event listener and channels
 func Listen() (*PsEvent, error) {
-	listener, err := createListener()
+	return ListenWithOptions(Options{})
+}
+
+// ListenWithOptions is like Listen, but additionally installs a kernel-side
+// BPF filter built from opts so that uninteresting messages are dropped by
+// the kernel before they ever reach our Recvfrom loop.
+func ListenWithOptions(opts Options) (*PsEvent, error) {
+	listener, err := createListener(opts)
 
 	if err != nil {
 		return nil, err
 	}
 
-	w := &PsEvent{
-		listener:    listener,
-		Fork:        make(chan *ProcEventFork),
-		Exec:        make(chan *ProcEventExec),
-		Exit:        make(chan *ProcEventExit),
-		Error:       make(chan error),
-		done:        make(chan bool, 1),
-		closedMutex: &sync.Mutex{},
-	}
-
+	w := newPsEvent(listener)
 	go w.readEvents()
 	return w, nil
 }
 
-// Read events from the netlink socket
+// newPsEvent allocates a PsEvent with every channel and bookkeeping field
+// initialized and listener attached. Split out of ListenWithOptions so
+// tests can exercise handleEvent, Watch/Unwatch and the shutdown path
+// directly against a listener-less PsEvent, without a real netlink socket.
+func newPsEvent(listener eventListener) *PsEvent {
+	return &PsEvent{
+		listener:     listener,
+		watches:      make(map[int]*watch),
+		watchesMutex: &sync.Mutex{},
+		Fork:         make(chan *ProcEventFork),
+		Exec:         make(chan *ProcEventExec),
+		Exit:         make(chan *ProcEventExit),
+		UID:          make(chan *ProcEventUID),
+		GID:          make(chan *ProcEventGID),
+		SID:          make(chan *ProcEventSID),
+		Ptrace:       make(chan *ProcEventPtrace),
+		Comm:         make(chan *ProcEventComm),
+		Coredump:     make(chan *ProcEventCoredump),
+		Error:        make(chan error),
+		done:         make(chan bool, 1),
+		closedMutex:  &sync.Mutex{},
+	}
+}
+
+// Watch adds pid to the watch table, restricting which event kinds
+// handleEvent delivers for it to those whose bit is set in flags (e.g.
+// PROC_EVENT_FORK|PROC_EVENT_EXIT). Once any watch is registered,
+// handleEvent only delivers an event if its subject pid is watched and the
+// event's type bit is set in that watch's flags; with no watches
+// registered, every event is delivered, matching prior behavior.
+func (p *PsEvent) Watch(pid int, flags uint32) error {
+	p.watchesMutex.Lock()
+	defer p.watchesMutex.Unlock()
+
+	p.watches[pid] = &watch{flags: flags}
+	return nil
+}
+
+// Unwatch removes pid from the watch table.
+func (p *PsEvent) Unwatch(pid int) error {
+	p.watchesMutex.Lock()
+	defer p.watchesMutex.Unlock()
+
+	delete(p.watches, pid)
+	return nil
+}
+
+// isWatching reports whether an event of the given kind should be
+// delivered for pid: true when no watches are registered at all, or when
+// pid is watched with that event kind's bit set in its flags.
+func (p *PsEvent) isWatching(pid int, event uint32) bool {
+	p.watchesMutex.Lock()
+	defer p.watchesMutex.Unlock()
+
+	if len(p.watches) == 0 {
+		return true
+	}
+
+	w, ok := p.watches[pid]
+	return ok && w.flags&event != 0
+}
+
+// Read events from the netlink socket. The loop blocks in
+// listener.waitReadable() between messages so it never busy-spins; Close()
+// wakes it on demand instead of leaving it parked in Recvfrom forever.
 func (p *PsEvent) readEvents() {
 	buf := make([]byte, syscall.Getpagesize())
 
 	listener, _ := p.listener.(*NetLink)
 
 	for {
-		if p.isDone() {
-			return
+		ready, err := listener.waitReadable()
+		if err != nil {
+			p.Error <- err
+			continue
+		}
+		if !ready {
+			// Close() woke us via wakeFd; drain and stop.
+			if p.isDone() {
+				return
+			}
+			continue
 		}
 
-		nr, _, err := syscall.Recvfrom(listener.sock, buf, 0)
+		nr, _, err := syscall.Recvfrom(listener.sock, buf, syscall.MSG_DONTWAIT)
 
 		if err != nil {
+			if err == syscall.EAGAIN {
+				continue
+			}
 			p.Error <- err
 			continue
 		}
@@ -98,7 +253,10 @@ func (p *PsEvent) readEvents() {
 
 		for _, m := range msgs {
 			if m.Header.Type == syscall.NLMSG_DONE {
-				p.handleEvent(m.Data)
+				if p.handleEvent(m.Data) {
+					p.finish()
+					return
+				}
 			}
 		}
 	}
@@ -109,13 +267,25 @@ func (p *PsEvent) finish() {
 	close(p.Fork)
 	close(p.Exec)
 	close(p.Exit)
+	close(p.UID)
+	close(p.GID)
+	close(p.SID)
+	close(p.Ptrace)
+	close(p.Comm)
+	close(p.Coredump)
 	close(p.Error)
 }
 
 // Dispatch events from the netlink socket to the Event channels.
 // Unlike bsd kqueue, netlink receives events for all pids,
-// so we apply filtering based on the watch table via isWatching()
-func (p *PsEvent) handleEvent(data []byte) {
+// so we apply filtering based on the watch table via isWatching().
+//
+// Every send below races against p.done: a caller that isn't draining one
+// of the nine event channels must not be able to wedge this goroutine
+// forever, since that would also stall delivery of every other event
+// kind. If done fires first, handleEvent abandons the send and reports
+// true so readEvents can finish() and stop.
+func (p *PsEvent) handleEvent(data []byte) bool {
 	buf := bytes.NewBuffer(data)
 	msg := &cnMsg{}
 	hdr := &procEventHeader{}
@@ -123,6 +293,8 @@ func (p *PsEvent) handleEvent(data []byte) {
 	_ = binary.Read(buf, byteOrder, msg)
 	_ = binary.Read(buf, byteOrder, hdr)
 
+	meta := EventMeta{CPU: hdr.Cpu, TimestampNs: hdr.Timestamp}
+
 	switch hdr.What {
 	case PROC_EVENT_FORK:
 		event := &forkProcEvent{}
@@ -131,24 +303,139 @@ func (p *PsEvent) handleEvent(data []byte) {
 		ppid := int(event.ParentTgid)
 		pid := int(event.ChildTgid)
 
-		p.Fork <- &ProcEventFork{ParentPid: ppid, ChildPid: pid}
+		if p.isWatching(ppid, PROC_EVENT_FORK) || p.isWatching(pid, PROC_EVENT_FORK) {
+			select {
+			case p.Fork <- &ProcEventFork{EventMeta: meta, ParentPid: ppid, ChildPid: pid}:
+			case <-p.done:
+				return true
+			}
+		}
 
 	case PROC_EVENT_EXEC:
 		event := &execProcEvent{}
 		_ = binary.Read(buf, byteOrder, event)
 		pid := int(event.ProcessTgid)
-		p.Exec <- &ProcEventExec{Pid: pid}
+		if p.isWatching(pid, PROC_EVENT_EXEC) {
+			select {
+			case p.Exec <- &ProcEventExec{EventMeta: meta, Pid: pid}:
+			case <-p.done:
+				return true
+			}
+		}
 
 	case PROC_EVENT_EXIT:
 		event := &exitProcEvent{}
 		_ = binary.Read(buf, byteOrder, event)
 		pid := int(event.ProcessTgid)
-		p.Exit <- &ProcEventExit{Pid: pid}
+		if p.isWatching(pid, PROC_EVENT_EXIT) {
+			select {
+			case p.Exit <- &ProcEventExit{
+				EventMeta:  meta,
+				Pid:        pid,
+				ExitCode:   int(event.ExitCode),
+				ExitSignal: int(event.ExitSignal),
+				ParentPid:  int(event.ParentPid),
+				ParentTgid: int(event.ParentTgid),
+			}:
+			case <-p.done:
+				return true
+			}
+		}
+
+	case PROC_EVENT_UID:
+		event := &idProcEvent{}
+		_ = binary.Read(buf, byteOrder, event)
+		pid := int(event.ProcessTgid)
+		if p.isWatching(pid, PROC_EVENT_UID) {
+			select {
+			case p.UID <- &ProcEventUID{EventMeta: meta, Pid: pid, RUID: int(event.R), EUID: int(event.E)}:
+			case <-p.done:
+				return true
+			}
+		}
+
+	case PROC_EVENT_GID:
+		event := &idProcEvent{}
+		_ = binary.Read(buf, byteOrder, event)
+		pid := int(event.ProcessTgid)
+		if p.isWatching(pid, PROC_EVENT_GID) {
+			select {
+			case p.GID <- &ProcEventGID{EventMeta: meta, Pid: pid, RGID: int(event.R), EGID: int(event.E)}:
+			case <-p.done:
+				return true
+			}
+		}
+
+	case PROC_EVENT_SID:
+		event := &sidProcEvent{}
+		_ = binary.Read(buf, byteOrder, event)
+		pid := int(event.ProcessTgid)
+		if p.isWatching(pid, PROC_EVENT_SID) {
+			select {
+			case p.SID <- &ProcEventSID{EventMeta: meta, Pid: pid}:
+			case <-p.done:
+				return true
+			}
+		}
+
+	case PROC_EVENT_PTRACE:
+		event := &ptraceProcEvent{}
+		_ = binary.Read(buf, byteOrder, event)
+		pid := int(event.ProcessTgid)
+		if p.isWatching(pid, PROC_EVENT_PTRACE) {
+			select {
+			case p.Ptrace <- &ProcEventPtrace{EventMeta: meta, Pid: pid, TracerPid: int(event.TracerTgid)}:
+			case <-p.done:
+				return true
+			}
+		}
+
+	case PROC_EVENT_COMM:
+		event := &commProcEvent{}
+		_ = binary.Read(buf, byteOrder, event)
+		pid := int(event.ProcessTgid)
+		if p.isWatching(pid, PROC_EVENT_COMM) {
+			comm := string(bytes.TrimRight(event.Comm[:], "\x00"))
+			select {
+			case p.Comm <- &ProcEventComm{EventMeta: meta, Pid: pid, Comm: comm}:
+			case <-p.done:
+				return true
+			}
+		}
+
+	case PROC_EVENT_COREDUMP:
+		event := &coredumpProcEvent{}
+		_ = binary.Read(buf, byteOrder, event)
+		pid := int(event.ProcessTgid)
+		if p.isWatching(pid, PROC_EVENT_COREDUMP) {
+			select {
+			case p.Coredump <- &ProcEventCoredump{EventMeta: meta, Pid: pid}:
+			case <-p.done:
+				return true
+			}
+		}
 	}
+
+	return false
 }
 
-// Closes the OS specific event listener,
+// Close signals readEvents() to stop, wakes it if it's parked in
+// waitReadable(), and closes the OS specific event listener. It is safe to
+// call more than once.
 func (p *PsEvent) Close() error {
+	p.closedMutex.Lock()
+	if p.isClosed {
+		p.closedMutex.Unlock()
+		return nil
+	}
+	p.isClosed = true
+	p.closedMutex.Unlock()
+
+	p.done <- true
+	if listener, ok := p.listener.(*NetLink); ok {
+		_ = listener.wake()
+	}
+
 	return p.listener.close()
 }
 
@@ -0,0 +1,42 @@
+package psevent
+
+import (
+	"reflect"
+	"testing"
+)
+
+// TestHandleEventExitFields asserts the exit-code/signal/parent fields
+// added to ProcEventExit, and the CPU/TimestampNs pair added to
+// EventMeta, are actually parsed off the wire - pwait_linux_test.go only
+// ever checked ev.Pid.
+func TestHandleEventExitFields(t *testing.T) {
+	p := newPsEvent(nil)
+
+	data := encodeProcEvent(t, PROC_EVENT_EXIT, 7, 123456789, &exitProcEvent{
+		ProcessPid:  100,
+		ProcessTgid: 101,
+		ExitCode:    2,
+		ExitSignal:  9,
+		ParentPid:   200,
+		ParentTgid:  201,
+	})
+
+	got := make(chan *ProcEventExit, 1)
+	go func() { got <- <-p.Exit }()
+
+	if stop := p.handleEvent(data); stop {
+		t.Fatal("handleEvent reported stop without done being signaled")
+	}
+
+	want := &ProcEventExit{
+		EventMeta:  EventMeta{CPU: 7, TimestampNs: 123456789},
+		Pid:        101,
+		ExitCode:   2,
+		ExitSignal: 9,
+		ParentPid:  200,
+		ParentTgid: 201,
+	}
+	if ev := <-got; !reflect.DeepEqual(ev, want) {
+		t.Fatalf("got %+v, want %+v", ev, want)
+	}
+}
@@ -0,0 +1,66 @@
+// High-level helpers built on top of Listen/Watch for the common "block
+// until some other process exits" use case.
+package psevent
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+)
+
+// ErrNoSuchProcess is returned by PWait/PWaitAny when none of the
+// requested pids exist, either because they had already exited before the
+// listener subscribed or because they never existed.
+var ErrNoSuchProcess = errors.New("psevent: no such process")
+
+// PWait blocks until pid exits, returning its ProcEventExit, or until ctx
+// is canceled. Unlike waiting on an *os.Process, pid need not be a child
+// of the calling process - this makes PWait a drop-in replacement for the
+// BSD pwait(1) idiom on Linux.
+func PWait(ctx context.Context, pid int) (*ProcEventExit, error) {
+	return PWaitAny(ctx, pid)
+}
+
+// PWaitAny blocks until any one of pids exits, returning the first
+// ProcEventExit observed, or until ctx is canceled.
+func PWaitAny(ctx context.Context, pids ...int) (*ProcEventExit, error) {
+	w, err := ListenWithOptions(Options{EventMask: PROC_EVENT_EXIT, PIDs: pids})
+	if err != nil {
+		return nil, err
+	}
+	defer w.Close()
+
+	for _, pid := range pids {
+		if err := w.Watch(pid, PROC_EVENT_EXIT); err != nil {
+			return nil, err
+		}
+	}
+
+	// A target may have exited in the window between opening the
+	// listener and subscribing to it; if none are still running there's
+	// no exit event left to observe.
+	if !anyProcessExists(pids) {
+		return nil, ErrNoSuchProcess
+	}
+
+	select {
+	case ev := <-w.Exit:
+		return ev, nil
+	case err := <-w.Error:
+		return nil, err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// anyProcessExists reports whether at least one of pids is currently
+// visible under /proc.
+func anyProcessExists(pids []int) bool {
+	for _, pid := range pids {
+		if _, err := os.Stat("/proc/" + strconv.Itoa(pid)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package psevent_test
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/dean2021/psevent"
+)
+
+// TestPWaitObservesExit spawns a child and waits for it via PWait, which
+// would hang until ctx expires if the EventMask-driven kernel filter
+// dropped the exit event - PWaitAny always sets EventMask, so this is a
+// direct regression test for the chunk0-3 offset bug.
+func TestPWaitObservesExit(t *testing.T) {
+	cmd := exec.Command("/bin/true")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	pid := cmd.Process.Pid
+	go func() { _ = cmd.Wait() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ev, err := psevent.PWait(ctx, pid)
+	if err != nil {
+		if err == ctx.Err() {
+			t.Fatal("PWait timed out waiting for exit - the kernel filter is likely dropping the event")
+		}
+		t.Skipf("proc connector unavailable in this environment: %v", err)
+	}
+	if ev.Pid != pid {
+		t.Fatalf("got exit for pid %d, want %d", ev.Pid, pid)
+	}
+}
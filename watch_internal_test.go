@@ -0,0 +1,103 @@
+package psevent
+
+import "testing"
+
+func TestIsWatchingDeliversEverythingByDefault(t *testing.T) {
+	p := newPsEvent(nil)
+
+	if !p.isWatching(1234, PROC_EVENT_EXIT) {
+		t.Fatal("isWatching should deliver every event when no watches are registered")
+	}
+}
+
+func TestWatchRestrictsToWatchedPidsAndFlags(t *testing.T) {
+	p := newPsEvent(nil)
+
+	if err := p.Watch(100, PROC_EVENT_EXIT); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+
+	if !p.isWatching(100, PROC_EVENT_EXIT) {
+		t.Fatal("watched pid with matching flag should be delivered")
+	}
+	if p.isWatching(100, PROC_EVENT_EXEC) {
+		t.Fatal("watched pid with a flag not in its mask should not be delivered")
+	}
+	if p.isWatching(200, PROC_EVENT_EXIT) {
+		t.Fatal("unwatched pid should not be delivered once any watch is registered")
+	}
+}
+
+func TestUnwatchRestoresDeliverEverything(t *testing.T) {
+	p := newPsEvent(nil)
+
+	if err := p.Watch(100, PROC_EVENT_EXIT); err != nil {
+		t.Fatalf("Watch: %v", err)
+	}
+	if err := p.Unwatch(100); err != nil {
+		t.Fatalf("Unwatch: %v", err)
+	}
+
+	if !p.isWatching(999, PROC_EVENT_EXIT) {
+		t.Fatal("removing the last watch should restore deliver-everything behavior")
+	}
+}
+
+// TestHandleEventForkWatchesEitherParentOrChild exercises the OR logic at
+// the top of the PROC_EVENT_FORK case: a fork is delivered if either the
+// parent or the child is being watched for it, since callers may only
+// know one side's pid in advance (e.g. they asked to watch a parent and
+// want to learn about its children via the resulting Fork event).
+func TestHandleEventForkWatchesEitherParentOrChild(t *testing.T) {
+	data := encodeProcEvent(t, PROC_EVENT_FORK, 0, 0, &forkProcEvent{
+		ParentPid: 10, ParentTgid: 10,
+		ChildPid: 20, ChildTgid: 20,
+	})
+
+	t.Run("parent watched", func(t *testing.T) {
+		p := newPsEvent(nil)
+		if err := p.Watch(10, PROC_EVENT_FORK); err != nil {
+			t.Fatalf("Watch: %v", err)
+		}
+
+		got := make(chan *ProcEventFork, 1)
+		go func() { got <- <-p.Fork }()
+
+		if stop := p.handleEvent(data); stop {
+			t.Fatal("handleEvent reported stop without done being signaled")
+		}
+		if ev := <-got; ev.ParentPid != 10 || ev.ChildPid != 20 {
+			t.Fatalf("got %+v", ev)
+		}
+	})
+
+	t.Run("child watched", func(t *testing.T) {
+		p := newPsEvent(nil)
+		if err := p.Watch(20, PROC_EVENT_FORK); err != nil {
+			t.Fatalf("Watch: %v", err)
+		}
+
+		got := make(chan *ProcEventFork, 1)
+		go func() { got <- <-p.Fork }()
+
+		if stop := p.handleEvent(data); stop {
+			t.Fatal("handleEvent reported stop without done being signaled")
+		}
+		if ev := <-got; ev.ParentPid != 10 || ev.ChildPid != 20 {
+			t.Fatalf("got %+v", ev)
+		}
+	})
+
+	t.Run("neither watched", func(t *testing.T) {
+		p := newPsEvent(nil)
+		if err := p.Watch(99, PROC_EVENT_FORK); err != nil {
+			t.Fatalf("Watch: %v", err)
+		}
+
+		// Nobody drains p.Fork; if isWatching incorrectly matched, this
+		// call would block forever instead of returning immediately.
+		if stop := p.handleEvent(data); stop {
+			t.Fatal("handleEvent reported stop without done being signaled")
+		}
+	})
+}